@@ -19,9 +19,13 @@ import (
 func NewDebugCommand(fs afero.Fs, mgr config.Manager) *cobra.Command {
 	command := &cobra.Command{
 		Use:   "debug",
-		Short: "Debug the local Redpanda process",
+		Short: "Debug the local Redpanda process, or another node via 'remote'",
 	}
 	command.AddCommand(debug.NewInfoCommand(fs, mgr))
+	command.AddCommand(debug.NewBundleCommand(fs, mgr))
+	command.AddCommand(debug.NewShellCommand(fs, mgr))
+	command.AddCommand(debug.NewRemoteCommand(fs, mgr))
+	command.AddCommand(debug.NewVersionCommand(fs, mgr))
 
 	debug.AddPlatformDependentCmds(fs, mgr, command)
 
@@ -0,0 +1,348 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+	vyaml "gopkg.in/yaml.v2"
+)
+
+// redactedFields are the redpanda.yaml keys whose values are replaced with
+// "REDACTED" when --redact is passed, so that the bundle can be attached to
+// a public support ticket without leaking credentials.
+var redactedFields = []string{
+	"sasl_password",
+	"scram_password",
+	"cloud_storage_secret_key",
+	"cloud_storage_access_key",
+}
+
+func NewBundleCommand(fs afero.Fs, mgr config.Manager) *cobra.Command {
+	var (
+		outFile string
+		redact  bool
+	)
+	command := &cobra.Command{
+		Use:   "bundle",
+		Short: "Collect a diagnostics bundle for a support ticket",
+		Long: `Collect a diagnostics bundle for a support ticket.
+
+This command gathers the redpanda configuration, recent log excerpts,
+'/proc' stats, 'journalctl' output for the redpanda service, kernel and OS
+info, the output of 'rpk debug info', and Kafka-level metadata reachable
+through the admin API, then archives it all into a single tar.gz that can
+be attached to a support ticket.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeBundle(fs, mgr, outFile, redact)
+		},
+	}
+	command.Flags().StringVarP(
+		&outFile,
+		"output",
+		"o",
+		defaultBundleName(),
+		"The file path to write the bundle to",
+	)
+	command.Flags().BoolVar(
+		&redact,
+		"redact",
+		false,
+		"Scrub credentials from redpanda.yaml before adding it to the bundle",
+	)
+	return command
+}
+
+func defaultBundleName() string {
+	return fmt.Sprintf("redpanda-bundle-%s.tar.gz", time.Now().Format("20060102150405"))
+}
+
+func executeBundle(fs afero.Fs, mgr config.Manager, outFile string, redact bool) error {
+	out, err := fs.Create(outFile)
+	if err != nil {
+		return fmt.Errorf("couldn't create bundle file '%s': %w", outFile, err)
+	}
+	defer out.Close()
+
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+
+	for _, step := range []struct {
+		name string
+		fn   func(*tar.Writer) error
+	}{
+		{"redpanda.yaml", func(tw *tar.Writer) error { return addConfig(tw, fs, mgr, redact) }},
+		{"logs", func(tw *tar.Writer) error { return addLogs(tw, fs) }},
+		{"proc", addProcStats},
+		{"journalctl", addJournalctl},
+		{"kernel/os info", addKernelInfo},
+		{"rpk debug info", addDebugInfo},
+		{"kafka metadata", func(tw *tar.Writer) error { return addKafkaMetadata(tw, fs, mgr) }},
+	} {
+		if err := step.fn(tw); err != nil {
+			addErrorNote(tw, step.name, err)
+		}
+	}
+
+	// tw and gzw are both buffered; a failure here means the archive on
+	// disk is truncated or corrupt, which must not be reported as success.
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("couldn't finalize bundle archive: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("couldn't finalize bundle compression: %w", err)
+	}
+	return nil
+}
+
+// addErrorNote records a collector failure inside the bundle itself, rather
+// than aborting the whole run: a partial bundle is still useful for support,
+// while a hard failure on e.g. a missing 'journalctl' binary isn't.
+func addErrorNote(tw *tar.Writer, step string, cause error) {
+	body := []byte(fmt.Sprintf("failed to collect %s: %v\n", step, cause))
+	hdr := &tar.Header{
+		Name: fmt.Sprintf("errors/%s.txt", strings.ReplaceAll(step, " ", "_")),
+		Mode: 0o644,
+		Size: int64(len(body)),
+	}
+	if tw.WriteHeader(hdr) == nil {
+		tw.Write(body)
+	}
+}
+
+func addConfig(tw *tar.Writer, fs afero.Fs, mgr config.Manager, redact bool) error {
+	path := config.DefaultPath
+	raw, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return err
+	}
+	if redact {
+		raw, err = redactConfig(raw)
+		if err != nil {
+			return err
+		}
+	}
+	info, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = "redpanda.yaml"
+	hdr.Size = int64(len(raw))
+	applyFileOwnership(hdr, info)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = tw.Write(raw)
+	return err
+}
+
+func redactConfig(raw []byte) ([]byte, error) {
+	var doc interface{}
+	if err := vyaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return vyaml.Marshal(redactValue(doc))
+}
+
+// redactValue walks an arbitrary YAML-decoded value and replaces any
+// redactedFields key's value with "REDACTED". It recurses into both maps
+// and sequences, since e.g. redpanda.yaml can list multiple kafka_api or
+// pandaproxy_client listener entries, each with its own credentials.
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(val))
+		for k, nested := range val {
+			if s, ok := k.(string); ok && isRedactedField(s) {
+				out[k] = "REDACTED"
+				continue
+			}
+			out[k] = redactValue(nested)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = redactValue(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+func isRedactedField(key string) bool {
+	for _, f := range redactedFields {
+		if strings.EqualFold(key, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// addFileToTar adds a single file from fs to the archive, cloning its mode,
+// uid and gid into the tar header so that an operator extracting the
+// bundle gets back the original file's permissions rather than whatever
+// the tar writer's defaults are.
+func addFileToTar(tw *tar.Writer, fs afero.Fs, path, arcName string) error {
+	info, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = arcName
+	applyFileOwnership(hdr, info)
+
+	f, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func addLogs(tw *tar.Writer, fs afero.Fs) error {
+	const logDir = "/var/log/redpanda"
+	matches, err := afero.Glob(fs, filepath.Join(logDir, "*.log"))
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := addFileToTar(tw, fs, m, filepath.Join("logs", filepath.Base(m))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addProcStats(tw *tar.Writer) error {
+	osFs := afero.NewOsFs()
+	for _, f := range []string{"/proc/meminfo", "/proc/cpuinfo", "/proc/diskstats", "/proc/loadavg"} {
+		if err := addFileToTar(tw, osFs, f, filepath.Join("proc", filepath.Base(f))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addJournalctl(tw *tar.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "journalctl", "-u", "redpanda", "--no-pager").Output()
+	if err != nil {
+		return fmt.Errorf("couldn't run journalctl: %w", err)
+	}
+	return addBytes(tw, "journalctl.txt", out)
+}
+
+func addKernelInfo(tw *tar.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "uname", "-a").Output()
+	if err != nil {
+		return fmt.Errorf("couldn't run uname: %w", err)
+	}
+	return addBytes(tw, "kernel.txt", out)
+}
+
+// addDebugInfo shells out to the currently running rpk binary's own
+// 'debug info' subcommand rather than reaching into its internals, so the
+// bundle always reflects exactly what a user invoking that command by hand
+// would see.
+func addDebugInfo(tw *tar.Writer) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, self, "debug", "info").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("couldn't run 'rpk debug info': %w", err)
+	}
+	return addBytes(tw, "info.txt", out)
+}
+
+func addKafkaMetadata(tw *tar.Writer, fs afero.Fs, mgr config.Manager) error {
+	cfg, err := mgr.Read(config.DefaultPath)
+	if err != nil {
+		return err
+	}
+	meta, err := fetchClusterMetadata(cfg)
+	if err != nil {
+		return err
+	}
+	return addBytes(tw, "kafka_metadata.json", meta)
+}
+
+// fetchClusterMetadata hits the admin API's cluster view endpoint directly;
+// it intentionally avoids pulling in a full Kafka client just to describe
+// topics for a support bundle.
+func fetchClusterMetadata(cfg *config.Config) ([]byte, error) {
+	if len(cfg.Redpanda.AdminApi) == 0 {
+		return nil, fmt.Errorf("no admin API addresses configured")
+	}
+	addr := cfg.Redpanda.AdminApi[0]
+	url := fmt.Sprintf("http://%s:%d/v1/cluster_view", addr.Address, addr.Port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't reach admin API at %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func addBytes(tw *tar.Writer, name string, body []byte) error {
+	hdr := &tar.Header{
+		Name: name,
+		Mode: 0o644,
+		Size: int64(len(body)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(body)
+	return err
+}
@@ -0,0 +1,29 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+// +build linux
+
+package debug
+
+import (
+	"archive/tar"
+	"os"
+	"syscall"
+)
+
+// applyFileOwnership copies the original file's uid and gid into the tar
+// header so that restoring the bundle on the same host preserves
+// ownership, not just the permission bits tar.FileInfoHeader fills in by
+// default.
+func applyFileOwnership(hdr *tar.Header, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		hdr.Uid = int(stat.Uid)
+		hdr.Gid = int(stat.Gid)
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+// +build !linux
+
+package debug
+
+import (
+	"archive/tar"
+	"os"
+)
+
+// applyFileOwnership is a no-op outside Linux: os.FileInfo.Sys() doesn't
+// expose a uid/gid we can rely on, so the bundle falls back to whatever
+// tar.FileInfoHeader already filled in.
+func applyFileOwnership(hdr *tar.Header, info os.FileInfo) {}
@@ -0,0 +1,75 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"archive/tar"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactConfig(t *testing.T) {
+	raw := []byte(`
+redpanda:
+  kafka_api:
+    - address: 0.0.0.0
+      port: 9092
+      sasl_password: s3cr3t
+    - address: 0.0.0.0
+      port: 9093
+      sasl_password: 0th3rs3cr3t
+  cloud_storage_secret_key: topsecret
+  directory: /var/lib/redpanda/data
+`)
+
+	redacted, err := redactConfig(raw)
+	require.NoError(t, err)
+
+	out := string(redacted)
+	require.NotContains(t, out, "s3cr3t")
+	require.NotContains(t, out, "0th3rs3cr3t")
+	require.NotContains(t, out, "topsecret")
+	require.Contains(t, out, "REDACTED")
+	require.Contains(t, out, "/var/lib/redpanda/data")
+	require.Equal(t, 3, strings.Count(out, "REDACTED"))
+}
+
+func TestAddFileToTar(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/var/log/redpanda/a.log", []byte("hello"), 0o640))
+
+	buf := &strings.Builder{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, addFileToTar(tw, fs, "/var/log/redpanda/a.log", "logs/a.log"))
+	require.NoError(t, tw.Close())
+
+	tr := tar.NewReader(strings.NewReader(buf.String()))
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	require.Equal(t, "logs/a.log", hdr.Name)
+	require.EqualValues(t, 0o640, hdr.Mode)
+}
+
+func TestAddFileToTarSkipsDirectories(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/var/log/redpanda", 0o755))
+
+	buf := &strings.Builder{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, addFileToTar(tw, fs, "/var/log/redpanda", "logs/redpanda"))
+	require.NoError(t, tw.Close())
+
+	tr := tar.NewReader(strings.NewReader(buf.String()))
+	_, err := tr.Next()
+	require.Error(t, err)
+}
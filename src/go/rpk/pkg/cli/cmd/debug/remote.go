@@ -0,0 +1,277 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NewRemoteCommand adds 'rpk debug remote', which runs the platform
+// dependent debug collectors against another node over SSH, so an operator
+// can debug a whole cluster from a jump host without installing rpk on
+// every broker.
+func NewRemoteCommand(fs afero.Fs, mgr config.Manager) *cobra.Command {
+	var (
+		target           string
+		identity         string
+		remoteSelf       string
+		outFile          string
+		skipHostKeyCheck bool
+	)
+	command := &cobra.Command{
+		Use:   "remote",
+		Short: "Run the debug subsystem against another node over SSH",
+		Long: `Run the debug subsystem against another node over SSH.
+
+This connects to the given '<user@host>', runs 'rpk debug bundle' there to
+exercise the same platform-dependent collectors 'rpk debug' would run
+locally, and streams the resulting bundle back to the invoking host. The
+remote's redpanda.yaml is fetched and layered onto the local config, and
+the remote's running redpanda PID is looked up, so disk paths and PIDs
+reported by the remote can be correlated against this host's view of the
+cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if target == "" {
+				return fmt.Errorf("--remote <user@host> is required")
+			}
+			local, err := mgr.Read(config.DefaultPath)
+			if err != nil {
+				return err
+			}
+			return runRemote(fs, target, identity, remoteSelf, outFile, skipHostKeyCheck, local)
+		},
+	}
+	command.Flags().StringVar(&target, "remote", "", "The user@host to run the debug subsystem against")
+	command.Flags().StringVar(&identity, "identity", defaultIdentityFile(), "Path to the SSH private key to authenticate with")
+	command.Flags().StringVar(&remoteSelf, "remote-rpk-path", "rpk", "Path to the rpk binary on the remote host")
+	command.Flags().StringVarP(&outFile, "output", "o", defaultBundleName(), "The file path to write the remote bundle to")
+	command.Flags().BoolVar(
+		&skipHostKeyCheck,
+		"insecure-skip-host-key-check",
+		false,
+		"Skip verifying the remote host's SSH key against ~/.ssh/known_hosts (insecure)",
+	)
+	return command
+}
+
+func defaultIdentityFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home + "/.ssh/id_rsa"
+}
+
+func runRemote(fs afero.Fs, target, identity, remoteRpkPath, outFile string, skipHostKeyCheck bool, local *config.Config) error {
+	sshUser, host := splitTarget(target)
+
+	client, err := dialSSH(sshUser, host, identity, skipHostKeyCheck)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to %s: %w", target, err)
+	}
+	defer client.Close()
+
+	remoteYaml, err := fetchRemoteConfig(client)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch remote redpanda.yaml: %w", err)
+	}
+
+	merged, err := mergeRemoteConfig(local, remoteYaml)
+	if err != nil {
+		return fmt.Errorf("couldn't merge remote config: %w", err)
+	}
+
+	if pid, err := remoteRedpandaPID(client); err != nil {
+		fmt.Printf("correlating against remote data dir %s (pid unknown: %v)\n", merged.Redpanda.Directory, err)
+	} else {
+		fmt.Printf("correlating against remote data dir %s (pid %s)\n", merged.Redpanda.Directory, pid)
+	}
+
+	bundle, err := collectRemoteBundle(client, remoteRpkPath)
+	if err != nil {
+		return fmt.Errorf("couldn't collect remote bundle: %w", err)
+	}
+	if err := afero.WriteFile(fs, outFile, bundle, 0o644); err != nil {
+		return fmt.Errorf("couldn't write remote bundle to %s: %w", outFile, err)
+	}
+	fmt.Printf("wrote remote bundle to %s\n", outFile)
+	return nil
+}
+
+// collectRemoteBundle runs 'rpk debug bundle' on the remote host - the
+// same platform-dependent collectors 'rpk debug' would run locally - and
+// streams the resulting archive back over the SSH session.
+func collectRemoteBundle(client *ssh.Client, remoteRpkPath string) ([]byte, error) {
+	remoteTmp := fmt.Sprintf("/tmp/rpk-debug-remote-bundle-%d.tar.gz", os.Getpid())
+	defer runRemoteCommand(client, fmt.Sprintf("rm -f %s", remoteTmp))
+
+	if _, err := runRemoteCommand(client, fmt.Sprintf("%s debug bundle -o %s", remoteRpkPath, remoteTmp)); err != nil {
+		return nil, fmt.Errorf("couldn't run remote 'rpk debug bundle': %w", err)
+	}
+	return fetchRemoteFile(client, remoteTmp)
+}
+
+// remoteRedpandaPID looks up the PID of the redpanda process running on
+// the remote host, so it can be correlated against the data directory and
+// collectors reported back from that host.
+func remoteRedpandaPID(client *ssh.Client) (string, error) {
+	out, err := runRemoteCommand(client, "pgrep -x redpanda")
+	if err != nil {
+		return "", err
+	}
+	pid := strings.TrimSpace(out)
+	if pid == "" {
+		return "", fmt.Errorf("no running redpanda process found on remote host")
+	}
+	return pid, nil
+}
+
+func splitTarget(target string) (user, host string) {
+	if i := strings.IndexByte(target, '@'); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return "", target
+}
+
+func dialSSH(sshUser, host, identity string, skipHostKeyCheck bool) (*ssh.Client, error) {
+	if sshUser == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't determine the current user; pass user@host explicitly: %w", err)
+		}
+		sshUser = u.Username
+	}
+
+	auths := []ssh.AuthMethod{}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auths = append(auths, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if key, err := ioutil.ReadFile(identity); err == nil {
+		if signer, err := ssh.ParsePrivateKey(key); err == nil {
+			auths = append(auths, ssh.PublicKeys(signer))
+		}
+	}
+
+	hostKeyCB, err := hostKeyCallback(skipHostKeyCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:            sshUser,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCB,
+	}
+
+	addr := host
+	if !strings.Contains(addr, ":") {
+		addr = addr + ":22"
+	}
+	return ssh.Dial("tcp", addr, cfg)
+}
+
+// hostKeyCallback verifies the remote's host key against the user's
+// known_hosts file, the same way the system 'ssh' client does, since
+// rpk debug remote carries SSH credentials and pulls redpanda.yaml
+// (which may contain secrets) off the remote box.
+func hostKeyCallback(skipHostKeyCheck bool) (ssh.HostKeyCallback, error) {
+	if skipHostKeyCheck {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't locate known_hosts: %w", err)
+	}
+	known := filepath.Join(home, ".ssh", "known_hosts")
+	cb, err := knownhosts.New(known)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s (pass --insecure-skip-host-key-check to bypass): %w", known, err)
+	}
+	return cb, nil
+}
+
+func runRemoteCommand(client *ssh.Client, cmd string) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	if err := session.Run(cmd); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+func fetchRemoteConfig(client *ssh.Client) ([]byte, error) {
+	return fetchRemoteFile(client, config.DefaultPath)
+}
+
+// fetchRemoteFile reads an arbitrary file off the remote host over the
+// same SSH connection used for running collectors.
+func fetchRemoteFile(client *ssh.Client, path string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	if err := session.Run(fmt.Sprintf("cat %s", path)); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// mergeRemoteConfig parses the remote's redpanda.yaml and layers its
+// broker-identifying fields (data directory, Kafka API and admin API
+// addresses) onto a copy of the local config, field by field, so that
+// correlating the remote's disk paths and PID against its reported
+// listeners doesn't lose anything local-only (like this host's own 'rpk'
+// client settings) that the remote didn't report.
+func mergeRemoteConfig(local *config.Config, remoteYaml []byte) (*config.Config, error) {
+	remote := &config.Config{}
+	if err := yaml.Unmarshal(remoteYaml, remote); err != nil {
+		return nil, err
+	}
+
+	merged := *local
+	if remote.Redpanda.Directory != "" {
+		merged.Redpanda.Directory = remote.Redpanda.Directory
+	}
+	if len(remote.Redpanda.KafkaApi) > 0 {
+		merged.Redpanda.KafkaApi = remote.Redpanda.KafkaApi
+	}
+	if len(remote.Redpanda.AdminApi) > 0 {
+		merged.Redpanda.AdminApi = remote.Redpanda.AdminApi
+	}
+	return &merged, nil
+}
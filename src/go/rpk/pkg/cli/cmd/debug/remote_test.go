@@ -0,0 +1,97 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+)
+
+func TestSplitTarget(t *testing.T) {
+	for _, tt := range []struct {
+		target   string
+		wantUser string
+		wantHost string
+	}{
+		{"ubuntu@node1", "ubuntu", "node1"},
+		{"node1", "", "node1"},
+		{"root@10.0.0.1", "root", "10.0.0.1"},
+	} {
+		user, host := splitTarget(tt.target)
+		require.Equal(t, tt.wantUser, user, tt.target)
+		require.Equal(t, tt.wantHost, host, tt.target)
+	}
+}
+
+func TestHostKeyCallbackSkip(t *testing.T) {
+	cb, err := hostKeyCallback(true)
+	require.NoError(t, err)
+	require.NotNil(t, cb)
+}
+
+func TestHostKeyCallbackVerifiesByDefault(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// No known_hosts file exists yet in this fresh temp home, so the
+	// default (non-skip) path must fail closed rather than silently
+	// accepting any host key.
+	_, err := hostKeyCallback(false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), filepath.Join(home, ".ssh", "known_hosts"))
+}
+
+func TestDialSSHResolvesCurrentUserWhenTargetHasNone(t *testing.T) {
+	// 198.51.100.1 is unroutable from a test sandbox, so the dial itself will
+	// fail; what we're asserting is that it fails for a connection
+	// reason, not because it couldn't figure out who the current user is
+	// (the bug this was fixed from: os.UserHomeDir() was being used as a
+	// username, e.g. "/root").
+	_, err := dialSSH("", "198.51.100.1:22", "/nonexistent", true)
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "couldn't determine the current user")
+}
+
+func TestMergeRemoteConfigLayersFieldByField(t *testing.T) {
+	local := &config.Config{}
+	local.Redpanda.Directory = "/var/lib/redpanda/local-data"
+	local.Redpanda.KafkaApi = []config.NamedSocketAddress{
+		{SocketAddress: config.SocketAddress{Address: "local-kafka", Port: 9092}},
+	}
+	local.Redpanda.AdminApi = []config.NamedSocketAddress{
+		{SocketAddress: config.SocketAddress{Address: "local-admin", Port: 9644}},
+	}
+
+	remoteYaml := []byte(`
+redpanda:
+  data_directory: /var/lib/redpanda/remote-data
+  admin:
+    - address: remote-admin
+      port: 9644
+`)
+
+	merged, err := mergeRemoteConfig(local, remoteYaml)
+	require.NoError(t, err)
+
+	require.Equal(t, "/var/lib/redpanda/remote-data", merged.Redpanda.Directory)
+	require.Equal(t, "remote-admin", merged.Redpanda.AdminApi[0].Address)
+	// The remote didn't report a kafka_api listener, so the local view is
+	// preserved rather than being wiped out by a wholesale overwrite.
+	require.Equal(t, local.Redpanda.KafkaApi, merged.Redpanda.KafkaApi)
+}
+
+func TestMergeRemoteConfigInvalidYaml(t *testing.T) {
+	local := &config.Config{}
+	_, err := mergeRemoteConfig(local, []byte("not: valid: yaml: at: all"))
+	require.Error(t, err)
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+)
+
+// shellSession holds everything a single REPL invocation needs to answer
+// its verbs without re-reading config for every line the user types; each
+// verb still queries the admin API afresh.
+type shellSession struct {
+	fs  afero.Fs
+	cfg *config.Config
+	out io.Writer
+}
+
+func NewShellCommand(fs afero.Fs, mgr config.Manager) *cobra.Command {
+	return &cobra.Command{
+		Use:   "shell",
+		Short: "Start an interactive shell for live cluster diagnosis",
+		Long: `Start an interactive shell for live cluster diagnosis.
+
+This drops you into a prompt showing the current broker/cluster context,
+where short verbs such as 'brokers', 'topics', 'partition <topic> <id>',
+'under-replicated' and 'diagnose' return tabular output without
+re-invoking rpk for each query.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := mgr.Read(config.DefaultPath)
+			if err != nil {
+				return err
+			}
+			return runShell(fs, cfg, os.Stdout)
+		},
+	}
+}
+
+func runShell(fs afero.Fs, cfg *config.Config, out io.Writer) error {
+	sess := &shellSession{fs: fs, cfg: cfg, out: out}
+
+	historyFile := filepath.Join(os.Getenv("HOME"), ".cache", "rpk", "debug_shell_history")
+	afero.NewOsFs().MkdirAll(filepath.Dir(historyFile), 0o755)
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          sess.prompt(),
+		HistoryFile:     historyFile,
+		AutoComplete:    sess.completer(),
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't start shell: %w", err)
+	}
+	defer rl.Close()
+
+	for {
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+		if err := sess.dispatch(line); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+	}
+}
+
+func (s *shellSession) prompt() string {
+	addr := "localhost:9092"
+	if len(s.cfg.Redpanda.KafkaApi) > 0 {
+		addr = fmt.Sprintf("%s:%d", s.cfg.Redpanda.KafkaApi[0].Address, s.cfg.Redpanda.KafkaApi[0].Port)
+	}
+	return fmt.Sprintf("%s(redpanda)> ", addr)
+}
+
+// completer builds the REPL's tab-completion tree: verbs complete
+// statically, while the argument after 'topics' or 'partition' completes
+// against topic names and the argument after 'brokers' completes against
+// broker addresses, both discovered live from the admin API on each tab
+// press.
+func (s *shellSession) completer() readline.AutoCompleter {
+	return readline.NewPrefixCompleter(
+		readline.PcItem("brokers", readline.PcItemDynamic(s.brokerNames)),
+		readline.PcItem("topics", readline.PcItemDynamic(s.topicNames)),
+		readline.PcItem("partition", readline.PcItemDynamic(s.topicNames)),
+		readline.PcItem("under-replicated"),
+		readline.PcItem("diagnose"),
+		readline.PcItem("exit"),
+	)
+}
+
+// topicNames and brokerNames are called by the readline completer on
+// every tab press, so a newly created topic or a broker that just joined
+// the cluster completes immediately without restarting the shell.
+func (s *shellSession) topicNames(string) []string {
+	topics, err := fetchTopics(s.cfg)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(topics))
+	for _, t := range topics {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func (s *shellSession) brokerNames(string) []string {
+	brokers, err := fetchBrokers(s.cfg)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(brokers))
+	for _, b := range brokers {
+		names = append(names, b.Address)
+	}
+	return names
+}
+
+func (s *shellSession) dispatch(line string) error {
+	fields := strings.Fields(line)
+	verb, rest := fields[0], fields[1:]
+
+	switch verb {
+	case "brokers":
+		return s.printBrokers()
+	case "topics":
+		return s.printTopics()
+	case "partition":
+		if len(rest) != 2 {
+			return fmt.Errorf("usage: partition <topic> <id>")
+		}
+		id, err := strconv.Atoi(rest[1])
+		if err != nil {
+			return fmt.Errorf("partition id must be a number: %w", err)
+		}
+		return s.printPartition(rest[0], id)
+	case "under-replicated":
+		return s.printUnderReplicated()
+	case "diagnose":
+		return s.diagnose()
+	default:
+		return fmt.Errorf("unknown verb %q; try brokers, topics, partition, under-replicated, diagnose", verb)
+	}
+}
+
+func (s *shellSession) printBrokers() error {
+	brokers, err := fetchBrokers(s.cfg)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tADDRESS\tSTATUS")
+	for _, b := range brokers {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", b.ID, b.Address, b.Status)
+	}
+	return tw.Flush()
+}
+
+func (s *shellSession) printTopics() error {
+	topics, err := fetchTopics(s.cfg)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPARTITIONS\tREPLICAS")
+	for _, t := range topics {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", t.Name, t.Partitions, t.Replicas)
+	}
+	return tw.Flush()
+}
+
+func (s *shellSession) printPartition(topic string, id int) error {
+	p, err := fetchPartition(s.cfg, topic, id)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tLEADER\tREPLICAS\tIN_SYNC")
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\n", topic, id, p.Leader, p.Replicas, p.InSyncReplicas)
+	return tw.Flush()
+}
+
+func (s *shellSession) printUnderReplicated() error {
+	partitions, err := fetchUnderReplicated(s.cfg)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tREPLICAS\tIN_SYNC")
+	for _, p := range partitions {
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\n", p.Topic, p.Partition, p.Replicas, p.InSyncReplicas)
+	}
+	return tw.Flush()
+}
+
+// diagnose walks the cluster and reports unhealthy shards/partitions:
+// unregistered, under-replicated, leaderless, or with out-of-sync
+// followers, alongside the offending node (the leader for an
+// under-replicated partition, the follower for an out-of-sync one, and 0
+// for unregistered or leaderless partitions, which name no node).
+func (s *shellSession) diagnose() error {
+	issues, err := diagnoseCluster(s.cfg)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		fmt.Fprintln(s.out, "no issues found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tISSUE\tNODE")
+	for _, i := range issues {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\n", i.Topic, i.Partition, i.Kind, i.Node)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+)
+
+// errAdminNotFound distinguishes a genuine "the admin API doesn't know
+// about this" (404) response from a transient transport or server error,
+// so callers like diagnoseCluster don't mistake API flakiness for a real
+// structural health finding.
+var errAdminNotFound = errors.New("not found")
+
+// broker, topic and partitionInfo mirror the shapes the admin API returns
+// for the corresponding 'rpk debug shell' verbs; clusterIssue is built
+// locally by diagnoseCluster from those responses.
+type broker struct {
+	ID      int    `json:"node_id"`
+	Address string `json:"address"`
+	Status  string `json:"status"`
+}
+
+type topic struct {
+	Name       string `json:"name"`
+	Partitions int    `json:"partition_count"`
+	Replicas   int    `json:"replication_factor"`
+}
+
+type partitionInfo struct {
+	Leader         int   `json:"leader_id"`
+	Replicas       []int `json:"replicas"`
+	InSyncReplicas []int `json:"in_sync_replicas"`
+}
+
+type clusterIssue struct {
+	Topic     string
+	Partition int
+	Kind      string
+	Node      int
+}
+
+func adminBaseURL(cfg *config.Config) (string, error) {
+	if len(cfg.Redpanda.AdminApi) == 0 {
+		return "", fmt.Errorf("no admin API addresses configured")
+	}
+	addr := cfg.Redpanda.AdminApi[0]
+	return fmt.Sprintf("http://%s:%d", addr.Address, addr.Port), nil
+}
+
+func adminGet(cfg *config.Config, path string, out interface{}) error {
+	base, err := adminBaseURL(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't reach admin API at %s: %w", base, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errAdminNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("admin API returned %s for %s", resp.Status, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func fetchBrokers(cfg *config.Config) ([]broker, error) {
+	var brokers []broker
+	if err := adminGet(cfg, "/v1/brokers", &brokers); err != nil {
+		return nil, err
+	}
+	return brokers, nil
+}
+
+func fetchTopics(cfg *config.Config) ([]topic, error) {
+	var topics []topic
+	if err := adminGet(cfg, "/v1/topics", &topics); err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+func fetchPartition(cfg *config.Config, topicName string, id int) (*partitionInfo, error) {
+	var p partitionInfo
+	path := fmt.Sprintf("/v1/topics/%s/partitions/%d", topicName, id)
+	if err := adminGet(cfg, path, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func fetchUnderReplicated(cfg *config.Config) ([]clusterIssue, error) {
+	all, err := diagnoseCluster(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var urp []clusterIssue
+	for _, i := range all {
+		if i.Kind == "under-replicated" {
+			urp = append(urp, i)
+		}
+	}
+	return urp, nil
+}
+
+// diagnoseCluster walks every topic and partition the admin API knows
+// about and flags unregistered, under-replicated, leaderless or
+// out-of-sync partitions.
+func diagnoseCluster(cfg *config.Config) ([]clusterIssue, error) {
+	topics, err := fetchTopics(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []clusterIssue
+	for _, t := range topics {
+		for id := 0; id < t.Partitions; id++ {
+			p, err := fetchPartition(cfg, t.Name, id)
+			if err != nil {
+				if errors.Is(err, errAdminNotFound) {
+					issues = append(issues, clusterIssue{Topic: t.Name, Partition: id, Kind: "unregistered"})
+					continue
+				}
+				return nil, fmt.Errorf("couldn't check %s/%d: %w", t.Name, id, err)
+			}
+			if p.Leader < 0 {
+				issues = append(issues, clusterIssue{Topic: t.Name, Partition: id, Kind: "leaderless"})
+				continue
+			}
+			if len(p.InSyncReplicas) < len(p.Replicas) {
+				issues = append(issues, clusterIssue{
+					Topic: t.Name, Partition: id, Kind: "under-replicated", Node: p.Leader,
+				})
+				inSync := make(map[int]bool, len(p.InSyncReplicas))
+				for _, r := range p.InSyncReplicas {
+					inSync[r] = true
+				}
+				for _, r := range p.Replicas {
+					if !inSync[r] {
+						issues = append(issues, clusterIssue{
+							Topic: t.Name, Partition: id, Kind: "out-of-sync", Node: r,
+						})
+					}
+				}
+			}
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+)
+
+func configForAdminServer(t *testing.T, srv *httptest.Server) *config.Config {
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	cfg := &config.Config{}
+	cfg.Redpanda.AdminApi = []config.NamedSocketAddress{{
+		SocketAddress: config.SocketAddress{Address: u.Hostname(), Port: port},
+	}}
+	return cfg
+}
+
+func TestFetchBrokersAndTopics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/brokers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"node_id":1,"address":"n1:9092","status":"healthy"}]`)
+	})
+	mux.HandleFunc("/v1/topics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"t1","partition_count":1,"replication_factor":1}]`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := configForAdminServer(t, srv)
+
+	brokers, err := fetchBrokers(cfg)
+	require.NoError(t, err)
+	require.Equal(t, []broker{{ID: 1, Address: "n1:9092", Status: "healthy"}}, brokers)
+
+	topics, err := fetchTopics(cfg)
+	require.NoError(t, err)
+	require.Equal(t, []topic{{Name: "t1", Partitions: 1, Replicas: 1}}, topics)
+}
+
+func TestDiagnoseClusterClassifiesNotFoundAsUnregistered(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/topics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"t1","partition_count":1,"replication_factor":1}]`)
+	})
+	mux.HandleFunc("/v1/topics/t1/partitions/0", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := configForAdminServer(t, srv)
+
+	issues, err := diagnoseCluster(cfg)
+	require.NoError(t, err)
+	require.Equal(t, []clusterIssue{{Topic: "t1", Partition: 0, Kind: "unregistered"}}, issues)
+}
+
+func TestDiagnoseClusterPropagatesTransientErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/topics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"t1","partition_count":1,"replication_factor":1}]`)
+	})
+	mux.HandleFunc("/v1/topics/t1/partitions/0", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := configForAdminServer(t, srv)
+
+	issues, err := diagnoseCluster(cfg)
+	require.Error(t, err)
+	require.Nil(t, issues)
+}
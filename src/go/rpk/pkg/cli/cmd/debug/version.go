@@ -0,0 +1,215 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+)
+
+// defaultRedpandaBin is where a production install places the redpanda
+// binary when it isn't reachable through a running process or $PATH.
+const defaultRedpandaBin = "/opt/redpanda/bin/redpanda"
+
+// version, rev and buildDate are overridden at link time via
+//
+//	-ldflags "-X .../debug.version=... -X .../debug.rev=... -X .../debug.buildDate=..."
+//
+// the same way the top-level 'rpk version' command is.
+var (
+	version   = "unknown"
+	rev       = "unknown"
+	buildDate = "unknown"
+)
+
+// versionReport is everything a support ticket needs to answer "which
+// versions are actually running" in one call.
+type versionReport struct {
+	RpkVersion      string   `json:"rpk_version"`
+	RpkRevision     string   `json:"rpk_revision"`
+	RpkBuildDate    string   `json:"rpk_build_date"`
+	BrokerVersion   string   `json:"broker_version"`
+	KafkaApiVersion string   `json:"kafka_api_version"`
+	FeatureFlags    []string `json:"feature_flags"`
+}
+
+func NewVersionCommand(fs afero.Fs, mgr config.Manager) *cobra.Command {
+	var format string
+	command := &cobra.Command{
+		Use:   "version",
+		Short: "Report rpk, broker and cluster feature-flag versions together",
+		Long: `Report rpk, broker and cluster feature-flag versions together.
+
+This prints, in one table, the rpk build (version, git SHA, build date),
+the local redpanda binary version, the first Kafka API version the admin
+API reports (no ApiVersions request is made over the Kafka protocol), and
+the cluster's enabled feature flags, so a bug
+report doesn't need three separate commands to answer "which versions
+are actually running".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := mgr.Read(config.DefaultPath)
+			if err != nil {
+				return err
+			}
+			report, err := collectVersionReport(cfg)
+			if err != nil {
+				return err
+			}
+			return renderVersionReport(format, os.Stdout, report)
+		},
+	}
+	command.Flags().StringVarP(&format, "format", "o", "text", "Output format (text, json)")
+	return command
+}
+
+// renderVersionReport validates the requested output format and writes
+// the report accordingly.
+func renderVersionReport(format string, out io.Writer, report *versionReport) error {
+	switch format {
+	case "json":
+		return printVersionJSON(out, report)
+	case "text":
+		return printVersionTable(out, report)
+	default:
+		return fmt.Errorf("unknown format %q; must be 'text' or 'json'", format)
+	}
+}
+
+func collectVersionReport(cfg *config.Config) (*versionReport, error) {
+	report := &versionReport{
+		RpkVersion:    version,
+		RpkRevision:   rev,
+		RpkBuildDate:  buildDate,
+		BrokerVersion: brokerVersion(),
+	}
+
+	apiVersion, err := negotiatedKafkaApiVersion(cfg)
+	if err != nil {
+		report.KafkaApiVersion = fmt.Sprintf("unknown (%v)", err)
+	} else {
+		report.KafkaApiVersion = apiVersion
+	}
+
+	flags, err := clusterFeatureFlags(cfg)
+	if err != nil {
+		report.FeatureFlags = []string{fmt.Sprintf("unknown (%v)", err)}
+	} else {
+		report.FeatureFlags = flags
+	}
+
+	return report, nil
+}
+
+// brokerVersion discovers the redpanda binary's version. It prefers the
+// executable of a currently running redpanda process (found via /proc),
+// since that's the binary actually serving traffic even if it isn't on
+// rpk's PATH (the common case for a systemd unit pointing at an absolute
+// path), then falls back to PATH and finally the conventional install
+// path.
+func brokerVersion() string {
+	candidates := []string{}
+	if exe, err := runningRedpandaExe(); err == nil {
+		candidates = append(candidates, exe)
+	}
+	candidates = append(candidates, "redpanda", defaultRedpandaBin)
+
+	for _, path := range candidates {
+		if out, err := redpandaVersion(path); err == nil {
+			return out
+		}
+	}
+	return fmt.Sprintf("unknown (no running redpanda process, and none found on PATH or at %s)", defaultRedpandaBin)
+}
+
+func redpandaVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// runningRedpandaExe scans /proc for a process named "redpanda" and
+// returns the absolute path to its executable.
+func runningRedpandaExe() (string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join("/proc", e.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "redpanda" {
+			continue
+		}
+		exe, err := os.Readlink(filepath.Join("/proc", e.Name(), "exe"))
+		if err != nil {
+			continue
+		}
+		return exe, nil
+	}
+	return "", fmt.Errorf("no running redpanda process found in /proc")
+}
+
+func negotiatedKafkaApiVersion(cfg *config.Config) (string, error) {
+	if len(cfg.Redpanda.AdminApi) == 0 {
+		return "", fmt.Errorf("no admin API addresses configured")
+	}
+	var versions []struct {
+		ApiVersion string `json:"api_version"`
+	}
+	if err := adminGet(cfg, "/v1/kafka/api_versions", &versions); err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no API versions reported")
+	}
+	return versions[0].ApiVersion, nil
+}
+
+func clusterFeatureFlags(cfg *config.Config) ([]string, error) {
+	var flags []string
+	if err := adminGet(cfg, "/v1/features", &flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+func printVersionTable(out io.Writer, report *versionReport) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "rpk version:\t%s (%s, built %s)\n", report.RpkVersion, report.RpkRevision, report.RpkBuildDate)
+	fmt.Fprintf(tw, "broker version:\t%s\n", report.BrokerVersion)
+	fmt.Fprintf(tw, "kafka api version:\t%s\n", report.KafkaApiVersion)
+	fmt.Fprintf(tw, "feature flags:\t%s\n", strings.Join(report.FeatureFlags, ", "))
+	return tw.Flush()
+}
+
+func printVersionJSON(out io.Writer, report *versionReport) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
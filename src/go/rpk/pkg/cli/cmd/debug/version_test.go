@@ -0,0 +1,88 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiatedKafkaApiVersionAndFeatureFlags(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/kafka/api_versions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"api_version":"v8"}]`)
+	})
+	mux.HandleFunc("/v1/features", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `["consumer_offsets", "raft_improvements"]`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := configForAdminServer(t, srv)
+
+	apiVersion, err := negotiatedKafkaApiVersion(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "v8", apiVersion)
+
+	flags, err := clusterFeatureFlags(cfg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"consumer_offsets", "raft_improvements"}, flags)
+}
+
+func TestCollectVersionReportFallsBackToUnknownOnAdminApiFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/kafka/api_versions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/v1/features", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := configForAdminServer(t, srv)
+
+	report, err := collectVersionReport(cfg)
+	require.NoError(t, err)
+	require.Contains(t, report.KafkaApiVersion, "unknown")
+	require.Len(t, report.FeatureFlags, 1)
+	require.Contains(t, report.FeatureFlags[0], "unknown")
+}
+
+func TestRenderVersionReportJSON(t *testing.T) {
+	report := &versionReport{RpkVersion: "1.2.3", BrokerVersion: "22.1.1"}
+
+	var buf bytes.Buffer
+	require.NoError(t, renderVersionReport("json", &buf, report))
+
+	var decoded versionReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, *report, decoded)
+}
+
+func TestRenderVersionReportText(t *testing.T) {
+	report := &versionReport{RpkVersion: "1.2.3", BrokerVersion: "22.1.1"}
+
+	var buf bytes.Buffer
+	require.NoError(t, renderVersionReport("text", &buf, report))
+	require.Contains(t, buf.String(), "1.2.3")
+	require.Contains(t, buf.String(), "22.1.1")
+}
+
+func TestRenderVersionReportRejectsUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := renderVersionReport("xml", &buf, &versionReport{})
+	require.Error(t, err)
+	require.Empty(t, buf.String())
+}